@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/cespare/xxhash/v2"
+	"github.com/zeebo/blake3"
+)
+
+// addHashFlags registers the --algo, --enc and --length flags shared by
+// the hash and hash-rename commands.
+func addHashFlags(cmd *kingpin.CmdClause) (algo *string, enc *string, length *int) {
+	algo = cmd.Flag("algo", fmt.Sprintf("Hash algorithm to use (%s)", strings.Join(hashAlgoNames(), ", "))).Default(defaultHashAlgo).String()
+	enc = cmd.Flag("enc", fmt.Sprintf("Digest encoding to use (%s)", strings.Join(hashEncNames(), ", "))).Default(defaultHashEnc).String()
+	length = cmd.Flag("length", "Truncate the encoded hash to this many characters (0 means full length)").Int()
+	return algo, enc, length
+}
+
+// defaultHashAlgo is used when --algo is unset.
+const defaultHashAlgo = "fnv32a"
+
+// defaultHashEnc is used when --enc is unset.
+const defaultHashEnc = "hex"
+
+// hashAlgo describes one of the hash algorithms selectable via --algo.
+type hashAlgo struct {
+	Name string
+	New  func() hash.Hash
+	Size int // raw digest size in bytes
+}
+
+// hashAlgos is the table of algorithms supported by --algo.
+var hashAlgos = map[string]hashAlgo{
+	"fnv32a": {Name: "fnv32a", New: func() hash.Hash { return fnv.New32a() }, Size: 4},
+	"fnv64a": {Name: "fnv64a", New: func() hash.Hash { return fnv.New64a() }, Size: 8},
+	"sha256": {Name: "sha256", New: func() hash.Hash { return sha256.New() }, Size: sha256.Size},
+	"blake3": {Name: "blake3", New: func() hash.Hash { return blake3.New() }, Size: 32},
+	"xxh64":  {Name: "xxh64", New: func() hash.Hash { return xxhash.New() }, Size: 8},
+}
+
+// hashAlgoNames returns the supported --algo values, sorted, for use in
+// flag help text and error messages.
+func hashAlgoNames() []string {
+	names := make([]string, 0, len(hashAlgos))
+	for n := range hashAlgos {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hashEnc describes one of the digest encodings selectable via --enc.
+type hashEnc struct {
+	Name   string
+	Encode func([]byte) string
+}
+
+// hashEncodings is the table of encodings supported by --enc. base32 is
+// emitted lowercase and unpadded, base64url unpadded (mixed case, since
+// lowercasing it would lose information), so slugs stay URL/filename
+// safe and shorter than hex.
+var hashEncodings = map[string]hashEnc{
+	"hex": {Name: "hex", Encode: func(b []byte) string {
+		return fmt.Sprintf("%x", b)
+	}},
+	"base32": {Name: "base32", Encode: func(b []byte) string {
+		return strings.ToLower(base32.HexEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+	}},
+	"base64url": {Name: "base64url", Encode: func(b []byte) string {
+		return base64.RawURLEncoding.EncodeToString(b)
+	}},
+}
+
+// hashEncNames returns the supported --enc values, sorted.
+func hashEncNames() []string {
+	names := make([]string, 0, len(hashEncodings))
+	for n := range hashEncodings {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hashFile computes the raw digest of the file at path using algoName
+// (one of hashAlgos), optionally consulting and updating cache to avoid
+// re-reading files that haven't changed since the last run.
+func hashFile(path string, algoName string, cache *hashCache) ([]byte, error) {
+	algo, ok := hashAlgos[algoName]
+	if !ok {
+		return nil, fmt.Errorf("unknown hash algorithm %q (supported: %s)", algoName, strings.Join(hashAlgoNames(), ", "))
+	}
+
+	if cache != nil {
+		if digest, ok := cache.lookup(path, algoName); ok {
+			return digest, nil
+		}
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	h := algo.New()
+	h.Write(b)
+	digest := h.Sum(nil)
+
+	if cache != nil {
+		cache.store(path, algoName, digest)
+	}
+
+	return digest, nil
+}
+
+// encodeHash encodes digest using encName (one of hashEncodings),
+// truncating the result to length characters when length > 0.
+func encodeHash(digest []byte, encName string, length int) (string, error) {
+	enc, ok := hashEncodings[encName]
+	if !ok {
+		return "", fmt.Errorf("unknown hash encoding %q (supported: %s)", encName, strings.Join(hashEncNames(), ", "))
+	}
+	s := enc.Encode(digest)
+	if length > 0 && length < len(s) {
+		s = s[:length]
+	}
+	return s, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// defaultCacheFileName is the persistent cache written alongside batch
+// hash/hash-rename runs so unchanged files don't need to be re-read and
+// re-hashed on every invocation, which matters when page-tmpl is
+// invoked repeatedly by the watcher.
+const defaultCacheFileName = ".vgutil-cache.json"
+
+// cacheKey identifies a cached digest: the absolute path of the hashed
+// file, its size and mtime at hash time, and the algorithm used. Any
+// change to the file, or a request for a different algorithm,
+// invalidates the entry. ModTime is stored as UnixNano rather than
+// time.Time because cacheKey is used as a map key compared with ==,
+// and time.Time's Location pointer is part of that comparison: a
+// fresh os.Stat returns mtimes in the Local location, but a round trip
+// through encoding/json always comes back in UTC, so comparing
+// time.Time values directly would make every lookup miss on the
+// process run after the cache was saved.
+type cacheKey struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mod_time"` // UnixNano
+	Algo    string `json:"algo"`
+}
+
+// cacheEntry is one record in the persisted cache file.
+type cacheEntry struct {
+	Key    cacheKey `json:"key"`
+	Digest string   `json:"digest"` // hex-encoded raw digest bytes
+}
+
+// hashCache is a persistent, on-disk cache of file digests keyed by
+// absolute path + size + mtime + algorithm. It is safe for concurrent
+// use.
+type hashCache struct {
+	path    string
+	mu      sync.Mutex
+	entries map[cacheKey]string // digest, hex-encoded
+	dirty   bool
+}
+
+// loadHashCache reads the cache file at path, if it exists. A missing
+// or unreadable cache file is treated as empty rather than an error, so
+// a corrupt cache never blocks a batch run.
+func loadHashCache(path string) *hashCache {
+	c := &hashCache{path: path, entries: make(map[cacheKey]string)}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	var list []cacheEntry
+	if err := json.Unmarshal(b, &list); err != nil {
+		log.Printf("Warning: ignoring unreadable cache file %q: %v", path, err)
+		return c
+	}
+	for _, e := range list {
+		c.entries[e.Key] = e.Digest
+	}
+	return c
+}
+
+// lookup returns the cached digest for path using algo, if the file's
+// current size and mtime still match what was cached.
+func (c *hashCache) lookup(path string, algo string) ([]byte, bool) {
+	key, ok := c.keyFor(path, algo)
+	if !ok {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	hexDigest, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	digest, err := hex.DecodeString(hexDigest)
+	if err != nil {
+		return nil, false
+	}
+	return digest, true
+}
+
+// store records digest for path/algo, keyed by the file's current size
+// and mtime.
+func (c *hashCache) store(path string, algo string, digest []byte) {
+	key, ok := c.keyFor(path, algo)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	c.entries[key] = hex.EncodeToString(digest)
+	c.dirty = true
+	c.mu.Unlock()
+}
+
+// keyFor stats path and builds the cacheKey used to index entries. It
+// returns ok=false if path can't be stat'd, in which case the caller
+// should fall through to computing the hash directly.
+func (c *hashCache) keyFor(path string, algo string) (cacheKey, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return cacheKey{}, false
+	}
+	st, err := os.Stat(abs)
+	if err != nil {
+		return cacheKey{}, false
+	}
+	return cacheKey{Path: abs, Size: st.Size(), ModTime: st.ModTime().UnixNano(), Algo: algo}, true
+}
+
+// save writes the cache to disk if it has been modified since it was
+// loaded.
+func (c *hashCache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+
+	list := make([]cacheEntry, 0, len(c.entries))
+	for k, v := range c.entries {
+		list = append(list, cacheEntry{Key: k, Digest: v})
+	}
+
+	b, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.path, b, 0644); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
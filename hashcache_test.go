@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestHashCacheRoundTrip verifies that a lookup still hits after the
+// cache has been saved to disk and reloaded in a fresh process, i.e.
+// after cacheKey.ModTime has gone through an encoding/json round trip.
+// This guards against comparing time.Time values (including their
+// Location) directly as a map key, since os.Stat returns mtimes in the
+// Local location while JSON round-trips them into UTC.
+func TestHashCacheRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	fname := filepath.Join(dir, "sample.txt")
+	if err := os.WriteFile(fname, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cachePath := filepath.Join(dir, ".vgutil-cache.json")
+	c := loadHashCache(cachePath)
+	digest, err := hashFile(fname, "sha256", c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a fresh process: reload the cache from the file we just
+	// wrote, exactly as loadHashCache would on the next invocation.
+	c2 := loadHashCache(cachePath)
+	got, ok := c2.lookup(fname, "sha256")
+	if !ok {
+		t.Fatal("lookup() missed after save/reload round trip; expected a hit")
+	}
+	if string(got) != string(digest) {
+		t.Fatalf("lookup() returned %x, want %x", got, digest)
+	}
+}
+
+// TestHashCacheJSONRoundTrip verifies cacheKey survives an
+// encoding/json round trip and still compares equal as a map key, even
+// though time.Time's Location differs between a freshly stat'd value
+// and one decoded from JSON (which always decodes in UTC).
+func TestHashCacheJSONRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	fname := filepath.Join(dir, "sample.txt")
+	if err := os.WriteFile(fname, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &hashCache{entries: make(map[cacheKey]string)}
+	key, ok := c.keyFor(fname, "sha256")
+	if !ok {
+		t.Fatal("keyFor failed")
+	}
+
+	b, err := json.Marshal(cacheEntry{Key: key, Digest: "abcd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e cacheEntry
+	if err := json.Unmarshal(b, &e); err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Key != key {
+		t.Fatalf("cacheKey changed across JSON round trip: got %+v, want %+v", e.Key, key)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// pagesRenderOptions controls a --pages-dir batch render.
+type pagesRenderOptions struct {
+	PagesDir     string
+	OutDir       string
+	Files        []string // same --files used to back FileName/FileExists
+	AssetBaseURL string
+	ManifestOut  string // path to write the manifest JSON to after the batch, empty to disable
+	Verbose      bool
+}
+
+// pageEntry is one input file discovered under PagesDir.
+type pageEntry struct {
+	relPath    string                 // path relative to PagesDir, e.g. "blog/post1.tmpl"
+	outRelPath string                 // path relative to OutDir, e.g. "blog/post1.html"
+	front      map[string]interface{} // parsed front matter, nil if none
+	body       string                 // template source with front matter stripped
+}
+
+// runPagesBatch walks opts.PagesDir for .tmpl/.md files, parses each
+// one's optional front matter, and renders every page through the
+// shared FuncMap (plus per-page Page/Pages funcs) into opts.OutDir,
+// preserving relative paths. A "_layout.tmpl" file, if present, is
+// parsed first as the page shell; individual pages then only need to
+// provide a {{define "content"}}...{{end}} block. Both discovery
+// errors (e.g. malformed front matter) and render errors are logged
+// per-page and collected, so one bad page never stops the rest of the
+// batch from rendering; runPagesBatch returns a non-nil error only if
+// at least one page failed.
+func runPagesBatch(opts pagesRenderOptions) error {
+	fmap := buildPageTmplFileMap(opts.Files, opts.Verbose)
+
+	pages, layoutSrc, hasLayout, discoverErrs, err := discoverPages(opts.PagesDir)
+	if err != nil {
+		return fmt.Errorf("discovering pages in %q: %w", opts.PagesDir, err)
+	}
+
+	var renderErrs []error
+	for _, derr := range discoverErrs {
+		log.Printf("Error: %v", derr)
+		renderErrs = append(renderErrs, derr)
+	}
+
+	pagesList := pagesTemplateData(pages)
+
+	for _, p := range pages {
+		if err := renderAndWritePage(p, layoutSrc, hasLayout, fmap, pagesList, opts); err != nil {
+			log.Printf("Error rendering %q: %v", p.relPath, err)
+			renderErrs = append(renderErrs, err)
+			continue
+		}
+		if opts.Verbose {
+			log.Printf("Rendered %q -> %q", p.relPath, p.outRelPath)
+		}
+	}
+
+	if opts.ManifestOut != "" {
+		if err := writePageTmplManifest(opts.ManifestOut, pageTmplManifest(fmap)); err != nil {
+			log.Printf("Could not write manifest %q: %v", opts.ManifestOut, err)
+		}
+	}
+
+	total := len(pages) + len(discoverErrs)
+	if len(renderErrs) > 0 {
+		return fmt.Errorf("%d of %d page(s) failed to render", len(renderErrs), total)
+	}
+	log.Printf("Rendered %d page(s) to %q", len(pages), opts.OutDir)
+	return nil
+}
+
+// discoverPages walks pagesDir collecting every .tmpl/.md file as a
+// pageEntry, except "_layout.tmpl" which is returned separately as the
+// shared page shell. A page whose front matter fails to parse (or that
+// can't be read) is skipped and its error appended to discoverErrs
+// rather than aborting the walk, so one bad page doesn't prevent the
+// rest of the directory from being discovered and rendered. err is
+// non-nil only for a failure walking the directory tree itself (e.g.
+// pagesDir doesn't exist).
+func discoverPages(pagesDir string) (pages []*pageEntry, layoutSrc string, hasLayout bool, discoverErrs []error, err error) {
+	err = filepath.WalkDir(pagesDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		ext := filepath.Ext(d.Name())
+		if ext != ".tmpl" && ext != ".md" {
+			return nil
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			discoverErrs = append(discoverErrs, fmt.Errorf("%s: %w", path, err))
+			return nil
+		}
+
+		if d.Name() == "_layout.tmpl" {
+			layoutSrc = string(b)
+			hasLayout = true
+			return nil
+		}
+
+		front, body, err := parseFrontMatter(string(b))
+		if err != nil {
+			discoverErrs = append(discoverErrs, fmt.Errorf("%s: %w", path, err))
+			return nil
+		}
+
+		rel, err := filepath.Rel(pagesDir, path)
+		if err != nil {
+			discoverErrs = append(discoverErrs, fmt.Errorf("%s: %w", path, err))
+			return nil
+		}
+
+		pages = append(pages, &pageEntry{
+			relPath:    rel,
+			outRelPath: strings.TrimSuffix(rel, ext) + ".html",
+			front:      front,
+			body:       body,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, "", false, nil, err
+	}
+
+	sort.Slice(pages, func(i, j int) bool { return pages[i].relPath < pages[j].relPath })
+	return pages, layoutSrc, hasLayout, discoverErrs, nil
+}
+
+// pagesTemplateData builds the value returned by the Pages template
+// func: each page's front matter plus its output Path, in path order.
+func pagesTemplateData(pages []*pageEntry) []map[string]interface{} {
+	list := make([]map[string]interface{}, len(pages))
+	for i, p := range pages {
+		list[i] = pageTemplateData(p)
+	}
+	return list
+}
+
+// pageTemplateData builds the value returned by the Page template func
+// for a single page: its front matter plus its output Path.
+func pageTemplateData(p *pageEntry) map[string]interface{} {
+	entry := make(map[string]interface{}, len(p.front)+1)
+	for k, v := range p.front {
+		entry[k] = v
+	}
+	entry["Path"] = p.outRelPath
+	return entry
+}
+
+// renderAndWritePage parses and executes p (against layoutSrc first, if
+// present) and writes the result under opts.OutDir.
+func renderAndWritePage(p *pageEntry, layoutSrc string, hasLayout bool, fmap map[string]pageTmplFile, pagesList []map[string]interface{}, opts pagesRenderOptions) error {
+	pageBaseName := strings.TrimSuffix(filepath.Base(p.relPath), filepath.Ext(p.relPath))
+	funcMap := newPageTmplFuncMap(fmap, pageBaseName, opts.AssetBaseURL, opts.Verbose)
+	funcMap["Page"] = func() map[string]interface{} { return pageTemplateData(p) }
+	funcMap["Pages"] = func() []map[string]interface{} { return pagesList }
+
+	tmpl := template.New("page").Funcs(funcMap)
+
+	var err error
+	if hasLayout {
+		tmpl, err = tmpl.Parse(layoutSrc)
+		if err != nil {
+			return fmt.Errorf("parsing _layout.tmpl: %w", err)
+		}
+	}
+	tmpl, err = tmpl.Parse(p.body)
+	if err != nil {
+		return fmt.Errorf("parsing template: %w", err)
+	}
+
+	var outBuf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&outBuf, "page", struct{}{}); err != nil {
+		return fmt.Errorf("executing template: %w", err)
+	}
+
+	outPath := filepath.Join(opts.OutDir, p.outRelPath)
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(outPath, outBuf.Bytes(), 0644)
+}
+
+// frontMatterDelims maps a front matter opening/closing delimiter to
+// the decode function used for the text between them.
+var frontMatterDelims = map[string]func(text string, out *map[string]interface{}) error{
+	"---": func(text string, out *map[string]interface{}) error {
+		return yaml.Unmarshal([]byte(text), out)
+	},
+	"+++": func(text string, out *map[string]interface{}) error {
+		_, err := toml.Decode(text, out)
+		return err
+	},
+}
+
+// parseFrontMatter splits an optional "---"/"+++"-delimited front
+// matter block off the top of src and decodes it as YAML or TOML
+// respectively. If src has no recognized front matter block, front is
+// nil and body is src unchanged.
+func parseFrontMatter(src string) (front map[string]interface{}, body string, err error) {
+	lines := strings.Split(src, "\n")
+	if len(lines) == 0 {
+		return nil, src, nil
+	}
+
+	delim := strings.TrimSpace(lines[0])
+	decode, ok := frontMatterDelims[delim]
+	if !ok {
+		return nil, src, nil
+	}
+
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != delim {
+			continue
+		}
+		fmText := strings.Join(lines[1:i], "\n")
+		front = make(map[string]interface{})
+		if err := decode(fmText, &front); err != nil {
+			return nil, src, fmt.Errorf("parsing %s front matter: %w", delim, err)
+		}
+		return front, strings.Join(lines[i+1:], "\n"), nil
+	}
+
+	return nil, src, errors.New("unterminated front matter block")
+}
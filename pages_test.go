@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRunPagesBatchIsolatesBadPage verifies that one page with
+// malformed front matter doesn't stop the rest of a --pages-dir batch
+// from rendering.
+func TestRunPagesBatchIsolatesBadPage(t *testing.T) {
+	pagesDir := t.TempDir()
+	outDir := filepath.Join(t.TempDir(), "out")
+
+	good := "hello"
+	if err := os.WriteFile(filepath.Join(pagesDir, "good.tmpl"), []byte(good), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bad := "---\ntitle: Bad\n" // opening "---" with no closing "---"
+	if err := os.WriteFile(filepath.Join(pagesDir, "bad.tmpl"), []byte(bad), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := runPagesBatch(pagesRenderOptions{PagesDir: pagesDir, OutDir: outDir})
+	if err == nil {
+		t.Fatal("expected an error because bad.tmpl failed to parse, got nil")
+	}
+
+	if _, err := os.Stat(filepath.Join(outDir, "good.html")); err != nil {
+		t.Fatalf("good.tmpl should still have rendered despite bad.tmpl failing: %v", err)
+	}
+}
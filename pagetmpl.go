@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// pageTmplFile is an entry in the file map made available to page
+// templates via FileName/FileExists, keyed by the file's slug-stripped
+// base name.
+type pageTmplFile struct {
+	name    string    // file name e.g. "whatever-abcd1234.css"
+	path    string    // file path as specified on the command line, including dir e.g. "./public/whatever-abcd1234.css"
+	modTime time.Time // file modification timestamp
+}
+
+// buildPageTmplFileMap stats each of files and returns the slug-stripped
+// name -> pageTmplFile map used to back the FileName/FileExists funcs.
+// Missing files are skipped with a warning; when two files strip to the
+// same key, the one with the newer modification time wins.
+func buildPageTmplFileMap(files []string, verbose bool) map[string]pageTmplFile {
+	fmap := make(map[string]pageTmplFile, len(files))
+	for _, fn := range files {
+		st, err := os.Stat(fn)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				log.Printf("Warning: Skipping missing file %q", fn)
+				continue
+			}
+			log.Fatalf("Error on input file %q: %v", fn, err)
+		}
+		name := filepath.Base(fn)
+		key := stripSlug(name)
+		fme := fmap[key]
+		if fme.modTime.IsZero() || st.ModTime().After(fme.modTime) {
+			fmap[key] = pageTmplFile{name: name, path: fn, modTime: st.ModTime()}
+		}
+	}
+	if verbose {
+		log.Printf("fmap after reading inputs: %#v", fmap)
+	}
+	return fmap
+}
+
+// newPageTmplFuncMap returns the FuncMap bound to fmap and pageBaseName
+// that is installed on every page-tmpl template. assetBaseURL is
+// prepended to file names returned by AssetURL, e.g. a CDN prefix.
+func newPageTmplFuncMap(fmap map[string]pageTmplFile, pageBaseName string, assetBaseURL string, verbose bool) template.FuncMap {
+	lookup := func(parts []string) (pageTmplFile, bool) {
+		fme, ok := fmap[strings.Join(parts, "")]
+		return fme, ok
+	}
+
+	return template.FuncMap{
+		"PageBaseName": func() string {
+			return pageBaseName
+		},
+		"FileName": func(parts ...string) (ret string) {
+			key := strings.Join(parts, "")
+			if verbose {
+				defer func() { log.Printf("FileName %q returning %q", key, ret) }()
+			}
+			fme, ok := fmap[key]
+			if !ok {
+				return ""
+			}
+			return fme.name
+		},
+		"FileExists": func(parts ...string) (ret bool) {
+			key := strings.Join(parts, "")
+			if verbose {
+				defer func() { log.Printf("FileExists %q returning %v", key, ret) }()
+			}
+			_, ok := fmap[key]
+			return ok
+		},
+		// AssetURL returns assetBaseURL plus the hashed file name, e.g. for
+		// pointing at a CDN or configurable static asset prefix.
+		"AssetURL": func(parts ...string) (string, error) {
+			fme, ok := lookup(parts)
+			if !ok {
+				return "", fmt.Errorf("AssetURL: no such file %q", strings.Join(parts, ""))
+			}
+			return assetBaseURL + fme.name, nil
+		},
+		// SRI returns the "sha384-<base64>" Subresource Integrity value for
+		// a file, computed from its bytes and cached across invocations.
+		"SRI": func(parts ...string) (string, error) {
+			fme, ok := lookup(parts)
+			if !ok {
+				return "", fmt.Errorf("SRI: no such file %q", strings.Join(parts, ""))
+			}
+			return computeSRI(fme.path)
+		},
+		// Manifest returns the full stripped-slug -> hashed-filename map,
+		// for index/listing templates that need to iterate all files.
+		"Manifest": func() map[string]string {
+			return pageTmplManifest(fmap)
+		},
+	}
+}
+
+// pageTmplManifest reduces fmap to the slug -> hashed-filename map
+// shared by the Manifest template func and the --manifest-out flag.
+func pageTmplManifest(fmap map[string]pageTmplFile) map[string]string {
+	m := make(map[string]string, len(fmap))
+	for k, v := range fmap {
+		m[k] = v.name
+	}
+	return m
+}
+
+// pageTmplResult is the outcome of a single renderPageTmpl call. Source
+// and Name are retained (even on success) so callers such as the
+// --serve error overlay can show file context around a later failure.
+type pageTmplResult struct {
+	HTML     []byte
+	Source   string            // raw template source that was parsed
+	Name     string            // template name used in Parse/Execute, for matching error locations
+	Manifest map[string]string // slug -> hashed-filename, for --manifest-out
+	Err      error
+}
+
+// renderPageTmpl parses and executes the template at inPath (or the
+// embedded default template when inPath is empty) against files,
+// returning the rendered HTML or a render error from either the parse
+// or execute phase. assetBaseURL is made available to the template via
+// the AssetURL func.
+func renderPageTmpl(inPath string, files []string, assetBaseURL string, verbose bool) pageTmplResult {
+	fmap := buildPageTmplFileMap(files, verbose)
+	manifest := pageTmplManifest(fmap)
+
+	pageBaseName := "index" // default if no --in param
+	source := defaultPageTmpl
+	if inPath != "" {
+		pageBaseName = strings.TrimSuffix(filepath.Base(inPath), filepath.Ext(inPath))
+		b, err := os.ReadFile(inPath)
+		if err != nil {
+			return pageTmplResult{Source: source, Name: "page", Manifest: manifest, Err: err}
+		}
+		source = string(b)
+	} else if verbose {
+		log.Printf("No --in template specified, using default")
+	}
+
+	tmpl := template.New("page").Funcs(newPageTmplFuncMap(fmap, pageBaseName, assetBaseURL, verbose))
+	tmpl, err := tmpl.Parse(source)
+	if err != nil {
+		return pageTmplResult{Source: source, Name: "page", Manifest: manifest, Err: err}
+	}
+
+	var data struct{}
+	var outBuf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&outBuf, "page", data); err != nil {
+		return pageTmplResult{Source: source, Name: "page", Manifest: manifest, Err: err}
+	}
+
+	return pageTmplResult{HTML: outBuf.Bytes(), Source: source, Name: "page", Manifest: manifest}
+}
+
+// writePageTmplManifest writes manifest to path as JSON, for the
+// --manifest-out flag, so downstream tools (service workers,
+// server-side handlers) can resolve logical names to hashed physical
+// names without re-parsing filenames.
+func writePageTmplManifest(path string, manifest map[string]string) error {
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"hash/fnv"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rjeczalik/notify"
+)
+
+// defaultPollInterval is used when --poll-interval is unset or zero.
+const defaultPollInterval = 2 * time.Second
+
+// defaultPollHashMaxSize is used when --poll-hash-max-size is unset or
+// zero. Files larger than this are compared by size/mtime/mode only,
+// since hashing large files on every poll cycle would be wasteful.
+const defaultPollHashMaxSize = 1 << 20 // 1MiB
+
+// pollFileInfo is a snapshot of a single file's relevant metadata,
+// taken on a poll cycle.
+type pollFileInfo struct {
+	Size    int64
+	ModTime time.Time
+	Mode    fs.FileMode
+	Hash    uint32 // FNV-1a content hash, only set when HasHash is true
+	HasHash bool
+}
+
+// pollEvent is a notify.EventInfo implementation synthesized by the
+// polling watcher, so it can be fed into the same channel (and
+// downstream debounce/exec pipeline) as native notify events.
+type pollEvent struct {
+	event notify.Event
+	path  string
+}
+
+func (e pollEvent) Event() notify.Event { return e.event }
+func (e pollEvent) Path() string        { return e.path }
+func (e pollEvent) Sys() interface{}    { return nil }
+
+// splitRecursive strips the notify "/..." recursive suffix from dir,
+// reporting whether it was present.
+func splitRecursive(dir string) (base string, recursive bool) {
+	if strings.HasSuffix(dir, "/...") {
+		return strings.TrimSuffix(dir, "/..."), true
+	}
+	return dir, false
+}
+
+// pollSnapshot walks dirs and returns a map of path to pollFileInfo for
+// every non-directory entry found. Non-recursive dirs (without the "/..."
+// suffix) are only read one level deep, matching notify's own
+// semantics.
+func pollSnapshot(dirs []string, hashMaxSize int64) (map[string]pollFileInfo, error) {
+	snap := make(map[string]pollFileInfo)
+
+	for _, dir := range dirs {
+		base, recursive := splitRecursive(dir)
+
+		walkFn := func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if !recursive && path != base {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			pfi := pollFileInfo{Size: info.Size(), ModTime: info.ModTime(), Mode: info.Mode()}
+			if info.Size() <= hashMaxSize {
+				if h, err := fnvHashFile(path); err == nil {
+					pfi.Hash = h
+					pfi.HasHash = true
+				}
+			}
+			snap[path] = pfi
+			return nil
+		}
+
+		if err := filepath.WalkDir(base, walkFn); err != nil {
+			return nil, err
+		}
+	}
+
+	return snap, nil
+}
+
+// fnvHashFile returns the 32-bit FNV-1a hash of a file's contents.
+func fnvHashFile(path string) (uint32, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	h := fnv.New32a()
+	h.Write(b)
+	return h.Sum32(), nil
+}
+
+// diffSnapshots compares two pollSnapshot results and returns the
+// synthesized events describing the difference. Removes and Creates
+// that share the same size and content hash within the cycle are
+// paired and reported as a single Rename event for each of the old and
+// new paths, matching how notify reports native renames.
+func diffSnapshots(old, cur map[string]pollFileInfo) []pollEvent {
+	var removed, created []string
+
+	for path := range old {
+		if _, ok := cur[path]; !ok {
+			removed = append(removed, path)
+		}
+	}
+	for path := range cur {
+		if _, ok := old[path]; !ok {
+			created = append(created, path)
+		}
+	}
+
+	var events []pollEvent
+
+	// detect modifications (path present in both, differs)
+	for path, ci := range cur {
+		oi, ok := old[path]
+		if !ok {
+			continue
+		}
+		if oi.Size != ci.Size || !oi.ModTime.Equal(ci.ModTime) || oi.Mode != ci.Mode ||
+			(oi.HasHash && ci.HasHash && oi.Hash != ci.Hash) {
+			events = append(events, pollEvent{event: notify.Write, path: path})
+		}
+	}
+
+	// pair removes/creates that share size+hash as renames
+	usedCreated := make(map[string]bool)
+	for _, rp := range removed {
+		ri := old[rp]
+		matched := ""
+		if ri.HasHash {
+			for _, cp := range created {
+				if usedCreated[cp] {
+					continue
+				}
+				ciInfo := cur[cp]
+				if ciInfo.HasHash && ciInfo.Size == ri.Size && ciInfo.Hash == ri.Hash {
+					matched = cp
+					break
+				}
+			}
+		}
+		if matched != "" {
+			usedCreated[matched] = true
+			events = append(events, pollEvent{event: notify.Rename, path: rp})
+			events = append(events, pollEvent{event: notify.Rename, path: matched})
+			continue
+		}
+		events = append(events, pollEvent{event: notify.Remove, path: rp})
+	}
+	for _, cp := range created {
+		if usedCreated[cp] {
+			continue
+		}
+		events = append(events, pollEvent{event: notify.Create, path: cp})
+	}
+
+	return events
+}
+
+// runPollWatch walks dirs on opts.PollInterval, diffing successive
+// snapshots and feeding the resulting synthetic events into out. It is
+// used in place of notify.Watch on filesystems (NFS, SMB, Docker bind
+// mounts, WSL2 cross-FS) where native events are unreliable or
+// unsupported. It runs until done is closed.
+func runPollWatch(dirs []string, opts watchOptions, out chan<- notify.EventInfo, done <-chan struct{}) error {
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	hashMaxSize := opts.PollHashMaxSize
+	if hashMaxSize <= 0 {
+		hashMaxSize = defaultPollHashMaxSize
+	}
+
+	prev, err := pollSnapshot(dirs, hashMaxSize)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-ticker.C:
+			cur, err := pollSnapshot(dirs, hashMaxSize)
+			if err != nil {
+				log.Printf("Error polling watched directories: %v", err)
+				continue
+			}
+			for _, ev := range diffSnapshots(prev, cur) {
+				out <- ev
+			}
+			prev = cur
+		}
+	}
+}
+
+// isUnsupportedWatchErr reports whether err indicates that the
+// underlying filesystem doesn't support native notify events (common on
+// NFS/SMB mounts, Docker bind mounts on macOS, and WSL2 cross-FS
+// setups), meaning the poll fallback should be used instead.
+func isUnsupportedWatchErr(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "not supported")
+}
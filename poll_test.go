@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rjeczalik/notify"
+)
+
+func hasEvent(events []pollEvent, ev notify.Event, path string) bool {
+	for _, e := range events {
+		if e.event == ev && e.path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// TestDiffSnapshotsRename verifies that a remove+create pair sharing
+// size and content hash is reported as a Rename for both the old and
+// new path, rather than as separate Remove/Create events.
+func TestDiffSnapshotsRename(t *testing.T) {
+	now := time.Unix(1000, 0)
+	old := map[string]pollFileInfo{
+		"/a/old.txt": {Size: 5, ModTime: now, Hash: 42, HasHash: true},
+	}
+	cur := map[string]pollFileInfo{
+		"/a/new.txt": {Size: 5, ModTime: now, Hash: 42, HasHash: true},
+	}
+
+	events := diffSnapshots(old, cur)
+
+	if !hasEvent(events, notify.Rename, "/a/old.txt") {
+		t.Errorf("expected a Rename event for /a/old.txt, got %+v", events)
+	}
+	if !hasEvent(events, notify.Rename, "/a/new.txt") {
+		t.Errorf("expected a Rename event for /a/new.txt, got %+v", events)
+	}
+	if hasEvent(events, notify.Remove, "/a/old.txt") || hasEvent(events, notify.Create, "/a/new.txt") {
+		t.Errorf("rename pair should not also produce Remove/Create events, got %+v", events)
+	}
+}
+
+// TestDiffSnapshotsRenameRequiresHashMatch verifies that a remove and a
+// create with matching size but different content hash are reported as
+// separate Remove/Create events, not paired as a rename.
+func TestDiffSnapshotsRenameRequiresHashMatch(t *testing.T) {
+	now := time.Unix(1000, 0)
+	old := map[string]pollFileInfo{
+		"/a/old.txt": {Size: 5, ModTime: now, Hash: 42, HasHash: true},
+	}
+	cur := map[string]pollFileInfo{
+		"/a/new.txt": {Size: 5, ModTime: now, Hash: 99, HasHash: true},
+	}
+
+	events := diffSnapshots(old, cur)
+
+	if !hasEvent(events, notify.Remove, "/a/old.txt") {
+		t.Errorf("expected a Remove event for /a/old.txt, got %+v", events)
+	}
+	if !hasEvent(events, notify.Create, "/a/new.txt") {
+		t.Errorf("expected a Create event for /a/new.txt, got %+v", events)
+	}
+	if hasEvent(events, notify.Rename, "/a/old.txt") || hasEvent(events, notify.Rename, "/a/new.txt") {
+		t.Errorf("mismatched hashes should not be paired as a rename, got %+v", events)
+	}
+}
+
+// TestDiffSnapshotsModify verifies that a path present in both
+// snapshots with a changed size/mtime/mode is reported as a Write.
+func TestDiffSnapshotsModify(t *testing.T) {
+	old := map[string]pollFileInfo{
+		"/a/f.txt": {Size: 5, ModTime: time.Unix(1000, 0), Hash: 1, HasHash: true},
+	}
+	cur := map[string]pollFileInfo{
+		"/a/f.txt": {Size: 6, ModTime: time.Unix(1001, 0), Hash: 2, HasHash: true},
+	}
+
+	events := diffSnapshots(old, cur)
+
+	if !hasEvent(events, notify.Write, "/a/f.txt") {
+		t.Errorf("expected a Write event for /a/f.txt, got %+v", events)
+	}
+}
+
+// TestDiffSnapshotsRenameOneToOne verifies that when multiple removes
+// could match a create by size alone, only the one with a matching
+// hash is paired, and it is paired at most once.
+func TestDiffSnapshotsRenameOneToOne(t *testing.T) {
+	now := time.Unix(1000, 0)
+	old := map[string]pollFileInfo{
+		"/a/one.txt": {Size: 5, ModTime: now, Hash: 1, HasHash: true},
+		"/a/two.txt": {Size: 5, ModTime: now, Hash: 2, HasHash: true},
+	}
+	cur := map[string]pollFileInfo{
+		"/a/two-renamed.txt": {Size: 5, ModTime: now, Hash: 2, HasHash: true},
+	}
+
+	events := diffSnapshots(old, cur)
+
+	if !hasEvent(events, notify.Rename, "/a/two.txt") || !hasEvent(events, notify.Rename, "/a/two-renamed.txt") {
+		t.Errorf("expected /a/two.txt renamed to /a/two-renamed.txt, got %+v", events)
+	}
+	if !hasEvent(events, notify.Remove, "/a/one.txt") {
+		t.Errorf("expected /a/one.txt reported as Remove (no hash match), got %+v", events)
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// pageTmplServeOptions controls the behavior of runPageTmplServe.
+type pageTmplServeOptions struct {
+	Addr         string
+	In           string   // template file, may be empty for the default template
+	Files        []string // files passed on the command line
+	AssetBaseURL string   // prefix returned by the AssetURL template func
+	ManifestOut  string   // path to write the manifest JSON to after each build, empty to disable
+}
+
+// runPageTmplServe starts an HTTP server on opts.Addr that serves the
+// current render of the page-tmpl template, watches its inputs, and
+// re-renders on change. Any other path that matches an asset's
+// AssetURL/FileName name is served directly from disk, so templates
+// using the default (empty) --asset-base-url work out of the box. When
+// a render fails, an HTML error overlay with file context is served in
+// place of the page; a small injected script reconnects via SSE and
+// reloads the tab once a subsequent rebuild succeeds. It blocks until
+// the server stops (normally via SIGINT, which terminates the
+// process).
+func runPageTmplServe(opts pageTmplServeOptions) error {
+	var (
+		mu     sync.RWMutex
+		build  pageTmplResult
+		assets map[string]pageTmplFile
+	)
+
+	clientsMu := sync.Mutex{}
+	clients := make(map[chan struct{}]bool)
+
+	notifyClients := func() {
+		clientsMu.Lock()
+		defer clientsMu.Unlock()
+		for ch := range clients {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+
+	rebuild := func() {
+		result := renderPageTmpl(opts.In, opts.Files, opts.AssetBaseURL, false)
+		fmap := buildPageTmplFileMap(opts.Files, false)
+		mu.Lock()
+		build = result
+		assets = fmap
+		mu.Unlock()
+		if result.Err != nil {
+			log.Printf("page-tmpl serve: build error: %v", result.Err)
+		} else {
+			log.Printf("page-tmpl serve: build ok (%d bytes)", len(result.HTML))
+		}
+		if opts.ManifestOut != "" {
+			if err := writePageTmplManifest(opts.ManifestOut, result.Manifest); err != nil {
+				log.Printf("page-tmpl serve: could not write manifest %q: %v", opts.ManifestOut, err)
+			}
+		}
+		notifyClients()
+	}
+
+	rebuild()
+
+	watchDirs := dedupWatchDirs(opts.In, opts.Files)
+	if len(watchDirs) > 0 {
+		go func() {
+			err := runWatch(watchDirs, watchOptions{
+				Debounce: defaultWatchDebounce,
+				OnBatch: func(files, kinds []string) {
+					log.Printf("page-tmpl serve: change detected, rebuilding (%s)", strings.Join(files, ", "))
+					rebuild()
+				},
+			})
+			if err != nil {
+				log.Printf("page-tmpl serve: watcher stopped: %v", err)
+				return
+			}
+			// runWatch returns nil only after it has consumed a SIGINT,
+			// which would otherwise have terminated the process.
+			os.Exit(0)
+		}()
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/__vgutil_livereload", func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		ch := make(chan struct{}, 1)
+		clientsMu.Lock()
+		clients[ch] = true
+		clientsMu.Unlock()
+		defer func() {
+			clientsMu.Lock()
+			delete(clients, ch)
+			clientsMu.Unlock()
+		}()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ch:
+				fmt.Fprintf(w, "data: reload\n\n")
+				flusher.Flush()
+			}
+		}
+	})
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		mu.RLock()
+		result := build
+		fmap := assets
+		mu.RUnlock()
+
+		if r.URL.Path != "/" {
+			if fme, ok := lookupPageTmplAsset(fmap, r.URL.Path); ok {
+				http.ServeFile(w, r, fme.path)
+				return
+			}
+		}
+
+		if result.Err != nil {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write(renderErrorOverlay(result, 5))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(injectLiveReloadScript(result.HTML))
+	})
+
+	log.Printf("page-tmpl serve: listening on %s", opts.Addr)
+	return http.ListenAndServe(opts.Addr, mux)
+}
+
+// lookupPageTmplAsset finds the file command-line file whose (possibly
+// slug-hashed) name matches the requested URL path, so that templates
+// referencing assets via AssetURL/FileName are actually served in
+// --serve mode rather than falling through to the page handler.
+// urlPath is expected in assetBaseURL-relative form, i.e. with no
+// scheme or host, as produced by the default (empty) --asset-base-url.
+func lookupPageTmplAsset(fmap map[string]pageTmplFile, urlPath string) (pageTmplFile, bool) {
+	name := strings.TrimPrefix(urlPath, "/")
+	for _, fme := range fmap {
+		if fme.name == name {
+			return fme, true
+		}
+	}
+	return pageTmplFile{}, false
+}
+
+// dedupWatchDirs returns the unique, non-recursive directories
+// containing in (if non-empty) and each of files, suitable for passing
+// to runWatch.
+func dedupWatchDirs(in string, files []string) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	add := func(p string) {
+		if p == "" {
+			return
+		}
+		dir := filepath.Dir(p)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	add(in)
+	for _, f := range files {
+		add(f)
+	}
+	return dirs
+}
+
+// templateErrLocRE matches the "template: NAME:LINE[:COL]: MESSAGE"
+// prefix that Go's text/template and html/template package use for
+// both parse errors and *template.ExecError values.
+var templateErrLocRE = regexp.MustCompile(`^template:\s*([^:]+):(\d+)(?::(\d+))?:\s*(.*)$`)
+
+// parseTemplateErrLoc extracts the template name, line, column (0 if
+// not present, e.g. for parse errors) and message from a template
+// error's string form. ok is false if the error didn't match the
+// expected format, in which case the overlay falls back to showing the
+// raw error text with no file context.
+func parseTemplateErrLoc(err error) (name string, line, col int, msg string, ok bool) {
+	m := templateErrLocRE.FindStringSubmatch(err.Error())
+	if m == nil {
+		return "", 0, 0, err.Error(), false
+	}
+	name = m[1]
+	line, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		col, _ = strconv.Atoi(m[3])
+	}
+	msg = m[4]
+	return name, line, col, msg, true
+}
+
+// renderErrorOverlay renders an HTML page describing result.Err, with
+// contextLines of source shown above and below the failing line when
+// the error location can be determined.
+func renderErrorOverlay(result pageTmplResult, contextLines int) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("<!DOCTYPE html><html><head><title>vgutil page-tmpl error</title>")
+	buf.WriteString(`<style>
+body { font-family: monospace; background: #1e1e1e; color: #ddd; margin: 0; padding: 2em; }
+h1 { color: #f55; font-size: 1.2em; }
+pre { background: #2a2a2a; padding: 1em; overflow: auto; border-radius: 4px; }
+.line { display: block; white-space: pre; }
+.line-err { background: #5a1d1d; }
+.line-no { display: inline-block; width: 3em; color: #888; text-align: right; margin-right: 1em; }
+</style>`)
+	buf.WriteString("</head><body>")
+	buf.WriteString("<h1>page-tmpl: template error</h1>")
+
+	_, line, _, msg, ok := parseTemplateErrLoc(result.Err)
+	if !ok {
+		msg = result.Err.Error()
+	}
+	fmt.Fprintf(&buf, "<pre>%s</pre>", html.EscapeString(msg))
+
+	if ok && line > 0 && result.Source != "" {
+		srcLines := strings.Split(result.Source, "\n")
+		start := line - 1 - contextLines
+		if start < 0 {
+			start = 0
+		}
+		end := line - 1 + contextLines
+		if end > len(srcLines)-1 {
+			end = len(srcLines) - 1
+		}
+
+		buf.WriteString("<pre>")
+		for i := start; i <= end; i++ {
+			class := "line"
+			if i == line-1 {
+				class = "line line-err"
+			}
+			fmt.Fprintf(&buf, `<span class="%s"><span class="line-no">%d</span>%s</span>`,
+				class, i+1, html.EscapeString(srcLines[i]))
+		}
+		buf.WriteString("</pre>")
+	}
+
+	buf.Write(liveReloadScript)
+	buf.WriteString("</body></html>")
+	return buf.Bytes()
+}
+
+// liveReloadScript is injected into every served page. It connects to
+// the livereload SSE endpoint and reloads the tab once a build
+// completes, so an open error overlay is replaced automatically as soon
+// as the underlying problem is fixed.
+var liveReloadScript = []byte(`<script>
+(function() {
+	var es = new EventSource("/__vgutil_livereload");
+	es.onmessage = function() { location.reload(); };
+})();
+</script>`)
+
+// injectLiveReloadScript appends the live reload script before
+// </body>, or at the end of html if no closing body tag is present.
+func injectLiveReloadScript(h []byte) []byte {
+	const marker = "</body>"
+	idx := bytes.LastIndex(bytes.ToLower(h), []byte(marker))
+	if idx < 0 {
+		out := make([]byte, 0, len(h)+len(liveReloadScript))
+		out = append(out, h...)
+		out = append(out, liveReloadScript...)
+		return out
+	}
+	out := make([]byte, 0, len(h)+len(liveReloadScript))
+	out = append(out, h[:idx]...)
+	out = append(out, liveReloadScript...)
+	out = append(out, h[idx:]...)
+	return out
+}
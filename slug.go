@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// minSlugLen is the shortest slug stripSlugRE will recognize. --length
+// lets a digest be truncated down to 1 char, but matching that short
+// would strip ordinary numeric filename suffixes (e.g. "v-2.css"), so we
+// floor the match length instead of tracking every possible --length
+// value.
+const minSlugLen = 4
+
+// slugCharClass describes the character class and range of valid
+// lengths produced by one digest encoding, used to build stripSlugRE.
+type slugCharClass struct {
+	class string // regexp character class body, e.g. "a-f0-9"
+	min   int    // shortest length to match (accounts for --length truncation)
+	max   int    // longest (natural, untruncated) length to match
+}
+
+// encodedLen returns the unpadded length, in characters, of a size-byte
+// digest encoded with enc.
+func encodedLen(encName string, size int) int {
+	switch encName {
+	case "hex":
+		return size * 2
+	case "base32":
+		return (size*8 + 4) / 5 // ceil(bits/5)
+	case "base64url":
+		return (size*8 + 5) / 6 // ceil(bits/6)
+	}
+	return 0
+}
+
+// slugCharClasses returns the char classes (hex, base32, base64url)
+// used by stripSlugRE, along with the range of lengths any enabled
+// algorithm could produce in that encoding, from minSlugLen (to allow
+// for --length truncation) up to the longest natural, untruncated
+// digest. This lets stripSlug recognize a slug regardless of which
+// --algo/--enc/--length combination produced it.
+func slugCharClasses() []slugCharClass {
+	classes := map[string]*slugCharClass{
+		"hex":       {class: "a-f0-9"},
+		"base32":    {class: "0-9a-v"},
+		"base64url": {class: "A-Za-z0-9_-"},
+	}
+
+	for _, algo := range hashAlgos {
+		for encName := range hashEncodings {
+			if l := encodedLen(encName, algo.Size); l > classes[encName].max {
+				classes[encName].max = l
+			}
+		}
+	}
+
+	out := make([]slugCharClass, 0, len(classes))
+	// stable order: hex, base32, base64url
+	for _, name := range []string{"hex", "base32", "base64url"} {
+		cc := *classes[name]
+		cc.min = minSlugLen
+		if cc.max < cc.min {
+			cc.min = cc.max
+		}
+		out = append(out, cc)
+	}
+	return out
+}
+
+// buildStripSlugRE builds the regexp used by stripSlug from
+// slugCharClasses, matching a leading "-" or "_", one of the known
+// slug shapes (at any length a --length-truncated digest could take),
+// and a trailing ".".
+func buildStripSlugRE() *regexp.Regexp {
+	var alts []string
+	for _, cc := range slugCharClasses() {
+		if cc.max <= 0 {
+			continue
+		}
+		// Go's regexp repetition is greedy, so among overlapping
+		// alternatives the longest match in range is always preferred,
+		// avoiding partial matches.
+		alts = append(alts, fmt.Sprintf("[%s]{%d,%d}", cc.class, cc.min, cc.max))
+	}
+	return regexp.MustCompile(`([_-](?:` + strings.Join(alts, "|") + `)[.])`)
+}
+
+// stripSlugRE recognizes a slug produced by any of the algorithm/
+// encoding combinations in hashAlgos/hashEncodings, at any length from
+// minSlugLen up to their natural (untruncated) length, so slugs
+// produced with --length still get recognized.
+var stripSlugRE = buildStripSlugRE()
+
+// stripSlug looks for a dash or underscore followed by a hash slug
+// (in any of the supported algorithms' encodings) and a period, and
+// removes it, e.g. "whatever-abcd1234.css" -> "whatever.css".
+func stripSlug(in string) (ret string) {
+	ret = stripSlugRE.ReplaceAllString(in, ".")
+	return ret
+}
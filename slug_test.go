@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestStripSlugRoundTrip verifies that, for every --algo/--enc
+// combination, a slug produced by encodeHash on a digest of that
+// algorithm is recognized and stripped by stripSlug. This guards
+// against the char classes in slugCharClasses drifting out of sync
+// with the alphabets hashEncodings actually emits (e.g. base32 using
+// base32.HexEncoding's "0-9a-v" alphabet rather than the standard
+// "a-z2-7" one).
+func TestStripSlugRoundTrip(t *testing.T) {
+	digest := []byte("0123456789abcdef0123456789abcdef")
+	for algoName, algo := range hashAlgos {
+		for encName := range hashEncodings {
+			slug, err := encodeHash(digest[:algo.Size], encName, 0)
+			if err != nil {
+				t.Fatalf("algo=%s enc=%s: encodeHash: %v", algoName, encName, err)
+			}
+			in := "style-" + slug + ".css"
+			got := stripSlug(in)
+			if got != "style.css" {
+				t.Errorf("algo=%s enc=%s: stripSlug(%q) = %q, want %q", algoName, encName, in, got, "style.css")
+			}
+		}
+	}
+}
+
+// TestStripSlugTruncated verifies that stripSlug still recognizes a
+// slug that was shortened with --length.
+func TestStripSlugTruncated(t *testing.T) {
+	digest := []byte("0123456789abcdef0123456789abcdef")
+	for encName := range hashEncodings {
+		slug, err := encodeHash(digest, encName, minSlugLen)
+		if err != nil {
+			t.Fatalf("enc=%s: encodeHash: %v", encName, err)
+		}
+		if len(slug) != minSlugLen {
+			t.Fatalf("enc=%s: encodeHash truncated to %d chars, want %d", encName, len(slug), minSlugLen)
+		}
+		in := "style-" + slug + ".css"
+		got := stripSlug(in)
+		if got != "style.css" {
+			t.Errorf("enc=%s: stripSlug(%q) = %q, want %q", encName, in, got, "style.css")
+		}
+	}
+}
+
+// TestStripSlugLeavesOrdinaryNames alone ensures minSlugLen keeps
+// short, non-hash numeric suffixes from being mistaken for a slug.
+func TestStripSlugLeavesOrdinaryNames(t *testing.T) {
+	for _, in := range []string{"v-2.css", "logo-1.png", "release-42.js"} {
+		if got := stripSlug(in); got != in {
+			t.Errorf("stripSlug(%q) = %q, want unchanged", in, got)
+		}
+	}
+}
+
+// TestStripSlugCharClassesMatchEncodings verifies that a 32-byte base32
+// digest from encodeHash uses only characters of the lowercase
+// base32hex alphabet, i.e. the "0-9a-v" class stripSlugRE uses for base32.
+func TestStripSlugCharClassesMatchEncodings(t *testing.T) {
+	digest := []byte("0123456789abcdef0123456789abcdef")
+	slug, err := encodeHash(digest, "base32", 0)
+	if err != nil {
+		t.Fatalf("encodeHash: %v", err)
+	}
+	const base32Alphabet = "0123456789abcdefghijklmnopqrstuv"
+	for _, c := range slug {
+		if !strings.ContainsRune(base32Alphabet, c) {
+			t.Fatalf("base32 slug %q contains char %q outside of %q", slug, c, base32Alphabet)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"os"
+	"sync"
+	"time"
+)
+
+// sriCacheEntry is one cached Subresource Integrity value, valid as
+// long as the source file's mtime hasn't changed.
+type sriCacheEntry struct {
+	modTime time.Time
+	value   string
+}
+
+// sriCache caches SRI values across template invocations (e.g.
+// successive rebuilds in `page-tmpl --serve`), so a file's hash isn't
+// recomputed on every render unless it actually changed.
+var sriCache sync.Map // path (string) -> sriCacheEntry
+
+// computeSRI returns the "sha384-<base64>" Subresource Integrity value
+// for the file at path, per the W3C SRI spec, using a cached value when
+// the file's mtime is unchanged since it was last computed.
+func computeSRI(path string) (string, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if v, ok := sriCache.Load(path); ok {
+		entry := v.(sriCacheEntry)
+		if entry.modTime.Equal(st.ModTime()) {
+			return entry.value, nil
+		}
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha512.Sum384(b)
+	value := "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+
+	sriCache.Store(path, sriCacheEntry{modTime: st.ModTime(), value: value})
+	return value, nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/rjeczalik/notify"
+)
+
+// defaultWatchDebounce is used when --debounce is unset or zero.
+const defaultWatchDebounce = 500 * time.Millisecond
+
+// watchOptions controls the behavior of runWatch.
+type watchOptions struct {
+	Debounce        time.Duration               // coalescing window for bursts of events
+	Exec            string                      // command template to run per burst, empty means print a summary
+	Exclude         []string                    // glob patterns (matched against full path or base name) to ignore
+	Verbose         bool
+	Poll            bool                        // force the polling fallback watcher instead of native notify events
+	PollInterval    time.Duration               // how often to re-walk watched trees in poll mode
+	PollHashMaxSize int64                       // files larger than this are compared by size/mtime/mode only
+	OnBatch         func(files, kinds []string) // if set, called per burst instead of --exec/summary below
+}
+
+// staticExts is the set of file extensions treated as "static" assets
+// (stylesheets, scripts, images, fonts) as opposed to "dynamic" source
+// files (Go, templates, etc.), mirroring the static/dynamic split Hugo's
+// watcher uses to decide how a change should be handled.
+var staticExts = map[string]bool{
+	".css": true, ".js": true, ".html": true, ".htm": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true,
+	".ico": true, ".woff": true, ".woff2": true, ".ttf": true, ".eot": true,
+}
+
+// tempFilePatterns lists the filename globs produced by editors and
+// other tooling that should never trigger a rebuild (Vim/Emacs swap and
+// lock files, Vim's "4913" existence probe, etc.).
+var tempFilePatterns = []string{"*~", ".#*", "*.swp", "4913"}
+
+// isTempFile reports whether name (a base file name, no directory
+// component) looks like an editor/tooling temp file that should be
+// ignored.
+func isTempFile(name string) bool {
+	for _, pat := range tempFilePatterns {
+		if ok, _ := filepath.Match(pat, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyBucket returns "static" or "dynamic" for path based on its
+// extension.
+func classifyBucket(path string) string {
+	if staticExts[strings.ToLower(filepath.Ext(path))] {
+		return "static"
+	}
+	return "dynamic"
+}
+
+// matchesAnyGlob reports whether path matches any of the given glob
+// patterns, tested against both the full path and the base name, so a
+// pattern like "*.tmp" matches by base name and a pattern with a
+// directory part must match the whole event path (absolute for native
+// notify events). "*" does not match across "/".
+func matchesAnyGlob(path string, globs []string) bool {
+	base := filepath.Base(path)
+	for _, g := range globs {
+		if ok, _ := filepath.Match(g, path); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(g, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// runWatch watches dirs (which may use the notify "/..." recursive
+// suffix) for changes, coalesces bursts of events using opts.Debounce,
+// and either logs a summary of each burst or runs opts.Exec with
+// {{.Files}}/{{.Kinds}} substituted. It blocks until interrupted with
+// SIGINT.
+func runWatch(dirs []string, opts watchOptions) error {
+
+	c := make(chan notify.EventInfo, 128)
+
+	usePoll := opts.Poll
+	if !usePoll {
+		watched := 0
+		for _, dir := range dirs {
+			if err := notify.Watch(dir, c, notify.All); err != nil {
+				if isUnsupportedWatchErr(err) {
+					log.Printf("notify.Watch does not support %q, falling back to --poll mode", dir)
+					usePoll = true
+					break
+				}
+				return fmt.Errorf("watching %q: %w", dir, err)
+			}
+			watched++
+		}
+		if usePoll && watched > 0 {
+			// undo any watches that did succeed before the unsupported dir
+			notify.Stop(c)
+		}
+	}
+
+	pollDone := make(chan struct{})
+	if usePoll {
+		go func() {
+			if err := runPollWatch(dirs, opts, c, pollDone); err != nil {
+				log.Printf("Error in poll watcher: %v", err)
+			}
+		}()
+		defer close(pollDone)
+	} else {
+		defer notify.Stop(c)
+	}
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt)
+
+	debounce := opts.Debounce
+	if debounce <= 0 {
+		debounce = defaultWatchDebounce
+	}
+
+	var (
+		mu      sync.Mutex
+		pending = make(map[string]notify.Event)
+		timer   *time.Timer
+	)
+
+	flush := func() {
+		mu.Lock()
+		batch := pending
+		pending = make(map[string]notify.Event)
+		mu.Unlock()
+
+		files, kinds := filterWatchBatch(batch, opts.Exclude)
+		if len(files) == 0 {
+			return
+		}
+
+		if opts.OnBatch != nil {
+			opts.OnBatch(files, kinds)
+			return
+		}
+
+		if opts.Exec == "" {
+			logWatchSummary(files)
+			return
+		}
+
+		if err := runWatchExec(opts.Exec, files, kinds); err != nil {
+			log.Printf("Error running --exec command: %v", err)
+		}
+	}
+
+	for {
+		select {
+		case ei, ok := <-c:
+			if !ok {
+				return nil
+			}
+			if opts.Verbose {
+				log.Printf("Event: %v", ei)
+			}
+			mu.Lock()
+			pending[ei.Path()] = ei.Event()
+			mu.Unlock()
+
+			if timer == nil {
+				timer = time.AfterFunc(debounce, flush)
+			} else {
+				timer.Reset(debounce)
+			}
+
+		case <-sigc:
+			log.Printf("Interrupted, stopping watch")
+			if timer != nil {
+				timer.Stop()
+			}
+			return nil
+		}
+	}
+}
+
+// filterWatchBatch drops temp files and anything matching excludeGlobs
+// from batch, returning the remaining paths and their event kinds
+// (stringified) in corresponding order.
+func filterWatchBatch(batch map[string]notify.Event, excludeGlobs []string) (files, kinds []string) {
+	for path, kind := range batch {
+		if isTempFile(filepath.Base(path)) {
+			continue
+		}
+		if matchesAnyGlob(path, excludeGlobs) {
+			continue
+		}
+		files = append(files, path)
+		kinds = append(kinds, kind.String())
+	}
+	return files, kinds
+}
+
+// logWatchSummary prints a single aggregated line describing a burst of
+// changes, broken down by static/dynamic bucket.
+func logWatchSummary(files []string) {
+	var statics, dynamics int
+	for _, f := range files {
+		if classifyBucket(f) == "static" {
+			statics++
+		} else {
+			dynamics++
+		}
+	}
+	log.Printf("Change detected: %d file(s) (%d static, %d dynamic): %s",
+		len(files), statics, dynamics, strings.Join(files, ", "))
+}
+
+// runWatchExec executes cmdline as a shell command after substituting
+// {{.Files}} and {{.Kinds}} template references.
+func runWatchExec(cmdline string, files, kinds []string) error {
+	tmpl, err := template.New("exec").Parse(cmdline)
+	if err != nil {
+		return fmt.Errorf("parsing --exec template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, struct {
+		Files []string
+		Kinds []string
+	}{Files: files, Kinds: kinds})
+	if err != nil {
+		return fmt.Errorf("executing --exec template: %w", err)
+	}
+
+	log.Printf("Running: %s", buf.String())
+	cmd := exec.Command("sh", "-c", buf.String())
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}